@@ -0,0 +1,21 @@
+package main
+
+import (
+	"context"
+	"io"
+)
+
+// Backend abstracts the object storage operations an S3FileNode needs in
+// order to serve reads, so a file node is no longer tied to a single
+// presigned HTTP URL. This is what lets a node be backed by a presigned
+// URL, a natively-signed S3 request, GCS, or Azure Blob interchangeably.
+type Backend interface {
+	// Stat returns the object's size in bytes and its ETag (empty if the
+	// backend doesn't expose one).
+	Stat(ctx context.Context) (size uint64, etag string, err error)
+
+	// ReadRange returns a reader over the half-open byte range
+	// [offset, offset+length). The caller owns the returned ReadCloser and
+	// must Close it once done.
+	ReadRange(ctx context.Context, offset int64, length int64) (io.ReadCloser, error)
+}
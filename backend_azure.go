@@ -0,0 +1,53 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"io"
+
+	"github.com/Azure/azure-sdk-for-go/sdk/storage/azblob"
+	"github.com/Azure/azure-sdk-for-go/sdk/storage/azblob/blob"
+)
+
+// AzureBlobBackend reads a blob directly from Azure Blob Storage.
+type AzureBlobBackend struct {
+	Client    *azblob.Client
+	Container string
+	Blob      string
+}
+
+func NewAzureBlobBackend(client *azblob.Client, container string, blobName string) *AzureBlobBackend {
+	return &AzureBlobBackend{Client: client, Container: container, Blob: blobName}
+}
+
+func (b *AzureBlobBackend) Stat(ctx context.Context) (uint64, string, error) {
+	props, err := b.Client.ServiceClient().NewContainerClient(b.Container).NewBlobClient(b.Blob).GetProperties(ctx, nil)
+	if err != nil {
+		return 0, "", fmt.Errorf("get properties %s/%s: %w", b.Container, b.Blob, err)
+	}
+
+	etag := ""
+	if props.ETag != nil {
+		etag = string(*props.ETag)
+	}
+
+	var size uint64
+	if props.ContentLength != nil {
+		size = uint64(*props.ContentLength)
+	}
+	return size, etag, nil
+}
+
+func (b *AzureBlobBackend) ReadRange(ctx context.Context, offset int64, length int64) (io.ReadCloser, error) {
+	resp, err := b.Client.DownloadStream(ctx, b.Container, b.Blob, &azblob.DownloadStreamOptions{
+		Range: blob.HTTPRange{
+			Offset: offset,
+			Count:  length,
+		},
+	})
+	if err != nil {
+		return nil, fmt.Errorf("download %s/%s range %d-%d: %w", b.Container, b.Blob, offset, offset+length-1, err)
+	}
+
+	return resp.Body, nil
+}
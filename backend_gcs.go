@@ -0,0 +1,40 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"io"
+
+	"cloud.google.com/go/storage"
+)
+
+// GCSBackend reads an object directly from Google Cloud Storage.
+type GCSBackend struct {
+	Client *storage.Client
+	Bucket string
+	Object string
+}
+
+func NewGCSBackend(client *storage.Client, bucket string, object string) *GCSBackend {
+	return &GCSBackend{Client: client, Bucket: bucket, Object: object}
+}
+
+func (b *GCSBackend) handle() *storage.ObjectHandle {
+	return b.Client.Bucket(b.Bucket).Object(b.Object)
+}
+
+func (b *GCSBackend) Stat(ctx context.Context) (uint64, string, error) {
+	attrs, err := b.handle().Attrs(ctx)
+	if err != nil {
+		return 0, "", fmt.Errorf("stat object gs://%s/%s: %w", b.Bucket, b.Object, err)
+	}
+	return uint64(attrs.Size), attrs.Etag, nil
+}
+
+func (b *GCSBackend) ReadRange(ctx context.Context, offset int64, length int64) (io.ReadCloser, error) {
+	r, err := b.handle().NewRangeReader(ctx, offset, length)
+	if err != nil {
+		return nil, fmt.Errorf("read object gs://%s/%s range %d-%d: %w", b.Bucket, b.Object, offset, offset+length-1, err)
+	}
+	return r, nil
+}
@@ -0,0 +1,77 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+)
+
+// PresignedURLBackend reads byte ranges from a presigned HTTP URL (e.g. an
+// S3 presigned GET). This is the original transport s3readfs used before
+// Backend existed, kept as the simplest option since it needs no
+// credentials wired into the process - at the cost of the URL eventually
+// expiring mid-mount.
+type PresignedURLBackend struct {
+	URL string
+}
+
+func NewPresignedURLBackend(url string) *PresignedURLBackend {
+	return &PresignedURLBackend{URL: url}
+}
+
+func (b *PresignedURLBackend) Stat(ctx context.Context) (uint64, string, error) {
+	req, err := http.NewRequestWithContext(ctx, "GET", b.URL, nil)
+	if err != nil {
+		return 0, "", err
+	}
+	req.Header.Set("Range", "bytes=0-0")
+
+	client := &http.Client{}
+	resp, err := client.Do(req)
+	if err != nil {
+		return 0, "", err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusPartialContent {
+		return 0, "", fmt.Errorf("expected status 206, got %d", resp.StatusCode)
+	}
+
+	contentRange := resp.Header.Get("Content-Range")
+	if contentRange == "" {
+		return 0, "", fmt.Errorf("Content-Range header not found")
+	}
+
+	// Parse the Content-Range header, e.g., "bytes 0-0/1289138071"
+	var start, end, size uint64
+	_, err = fmt.Sscanf(contentRange, "bytes %d-%d/%d", &start, &end, &size)
+	if err != nil {
+		return 0, "", fmt.Errorf("failed to parse Content-Range: %v", err)
+	}
+
+	return size, resp.Header.Get("ETag"), nil
+}
+
+func (b *PresignedURLBackend) ReadRange(ctx context.Context, offset int64, length int64) (io.ReadCloser, error) {
+	req, err := http.NewRequestWithContext(ctx, "GET", b.URL, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	rangeHeader := fmt.Sprintf("bytes=%d-%d", offset, offset+length-1)
+	req.Header.Set("Range", rangeHeader)
+
+	client := &http.Client{}
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+
+	if resp.StatusCode != http.StatusPartialContent {
+		resp.Body.Close()
+		return nil, fmt.Errorf("expected status 206, got %d", resp.StatusCode)
+	}
+
+	return resp.Body, nil
+}
@@ -0,0 +1,53 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"io"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+)
+
+// S3Backend reads an object directly from S3, signing each request with
+// SigV4 using long-lived credentials instead of relying on a presigned URL
+// that expires mid-mount.
+type S3Backend struct {
+	Client *s3.Client
+	Bucket string
+	Key    string
+}
+
+func NewS3Backend(client *s3.Client, bucket string, key string) *S3Backend {
+	return &S3Backend{Client: client, Bucket: bucket, Key: key}
+}
+
+func (b *S3Backend) Stat(ctx context.Context) (uint64, string, error) {
+	out, err := b.Client.HeadObject(ctx, &s3.HeadObjectInput{
+		Bucket: aws.String(b.Bucket),
+		Key:    aws.String(b.Key),
+	})
+	if err != nil {
+		return 0, "", fmt.Errorf("head object s3://%s/%s: %w", b.Bucket, b.Key, err)
+	}
+
+	etag := ""
+	if out.ETag != nil {
+		etag = *out.ETag
+	}
+	return uint64(aws.ToInt64(out.ContentLength)), etag, nil
+}
+
+func (b *S3Backend) ReadRange(ctx context.Context, offset int64, length int64) (io.ReadCloser, error) {
+	rangeHeader := fmt.Sprintf("bytes=%d-%d", offset, offset+length-1)
+	out, err := b.Client.GetObject(ctx, &s3.GetObjectInput{
+		Bucket: aws.String(b.Bucket),
+		Key:    aws.String(b.Key),
+		Range:  aws.String(rangeHeader),
+	})
+	if err != nil {
+		return nil, fmt.Errorf("get object s3://%s/%s range %s: %w", b.Bucket, b.Key, rangeHeader, err)
+	}
+
+	return out.Body, nil
+}
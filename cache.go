@@ -0,0 +1,194 @@
+package main
+
+import (
+	"container/list"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+	"syscall"
+
+	"github.com/jacobsa/fuse/fuseops"
+)
+
+// blockKey identifies a cached block by the inode it belongs to and the
+// block-aligned offset within that file.
+type blockKey struct {
+	Inode  fuseops.InodeID
+	Offset int64
+}
+
+// blockEntry is the value stored in BlockCache.order; Data is nil once the
+// block has been spilled to disk, in which case SpillPath names the backing
+// file and must be mmap'd to read it back.
+type blockEntry struct {
+	key       blockKey
+	data      []byte
+	spillPath string
+	size      int64
+}
+
+// BlockCache is a single LRU shared by every S3FileNode in an S3FSRead,
+// bounded by maxBytes of in-memory block data. This replaces the old
+// per-file CacheBlock map, which grew without bound (eviction only dropped
+// blocks *before* the current read offset, so backward seeks never freed
+// anything) and wasn't shared, so mounting N files pinned N times the
+// intended memory budget.
+type BlockCache struct {
+	mu       sync.Mutex
+	maxBytes int64
+	curBytes int64
+	items    map[blockKey]*list.Element
+	order    *list.List
+
+	spillDir string
+}
+
+// NewBlockCache creates a cache bounded at maxBytes of in-memory block data.
+// If spillDir is non-empty, blocks evicted under memory pressure are
+// written there instead of being dropped, and mmap'd back in on next
+// access, so warm blocks survive beyond the in-memory budget.
+func NewBlockCache(maxBytes int64, spillDir string) *BlockCache {
+	return &BlockCache{
+		maxBytes: maxBytes,
+		items:    make(map[blockKey]*list.Element),
+		order:    list.New(),
+		spillDir: spillDir,
+	}
+}
+
+// Get returns the cached block for key, promoting it to most-recently-used.
+func (c *BlockCache) Get(key blockKey) ([]byte, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	elem, ok := c.items[key]
+	if !ok {
+		return nil, false
+	}
+	c.order.MoveToFront(elem)
+
+	entry := elem.Value.(*blockEntry)
+	if entry.data != nil {
+		return entry.data, true
+	}
+
+	data, err := readSpillFile(entry.spillPath, entry.size)
+	if err != nil {
+		// Treat a spill-read failure as a cache miss; the caller will refetch.
+		c.removeLocked(elem)
+		return nil, false
+	}
+
+	// Promote the block back into the in-memory budget instead of leaving it
+	// mmap'd: every previous Get() here mmap'd a fresh mapping and never
+	// unmapped it, so RSS grew by one block-sized mapping per access to a
+	// cold block forever. Copy off the heap, drop the mapping immediately,
+	// and let the normal eviction path account for the bytes going forward.
+	_ = os.Remove(entry.spillPath)
+	entry.spillPath = ""
+	entry.data = data
+	c.curBytes += entry.size
+
+	for c.curBytes > c.maxBytes {
+		back := c.order.Back()
+		if back == nil || back == elem {
+			break
+		}
+		c.evictLocked(back)
+	}
+
+	return data, true
+}
+
+// Put inserts data for key as the most-recently-used block, evicting
+// least-recently-used blocks (spilling them to disk if configured) until
+// the cache fits within maxBytes again.
+func (c *BlockCache) Put(key blockKey, data []byte) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if elem, ok := c.items[key]; ok {
+		c.removeLocked(elem)
+	}
+
+	entry := &blockEntry{key: key, data: data, size: int64(len(data))}
+	elem := c.order.PushFront(entry)
+	c.items[key] = elem
+	c.curBytes += entry.size
+
+	for c.curBytes > c.maxBytes {
+		back := c.order.Back()
+		if back == nil || back == elem {
+			break
+		}
+		c.evictLocked(back)
+	}
+}
+
+// evictLocked removes the in-memory copy of the block at back, spilling it
+// to disk first when a spill directory is configured; the list entry is
+// kept (now pointing at the spill file) until it is next touched.
+func (c *BlockCache) evictLocked(elem *list.Element) {
+	entry := elem.Value.(*blockEntry)
+	c.curBytes -= entry.size
+
+	if c.spillDir == "" || entry.data == nil {
+		c.removeLocked(elem)
+		return
+	}
+
+	path, err := writeSpillFile(c.spillDir, entry.key, entry.data)
+	if err != nil {
+		// Best effort: if we can't spill, just drop the block.
+		c.removeLocked(elem)
+		return
+	}
+
+	entry.spillPath = path
+	entry.data = nil
+}
+
+func (c *BlockCache) removeLocked(elem *list.Element) {
+	entry := elem.Value.(*blockEntry)
+	if entry.spillPath != "" {
+		_ = os.Remove(entry.spillPath)
+	}
+	delete(c.items, entry.key)
+	c.order.Remove(elem)
+}
+
+func spillFileName(spillDir string, key blockKey) string {
+	return filepath.Join(spillDir, fmt.Sprintf("%d-%d.block", key.Inode, key.Offset))
+}
+
+func writeSpillFile(spillDir string, key blockKey, data []byte) (string, error) {
+	path := spillFileName(spillDir, key)
+	if err := os.WriteFile(path, data, 0600); err != nil {
+		return "", fmt.Errorf("spill block to %s: %w", path, err)
+	}
+	return path, nil
+}
+
+// readSpillFile mmaps the spilled block just long enough to copy it onto the
+// heap, then unmaps it. The mapping is never kept around: the caller folds
+// the copy back into BlockCache's in-memory budget (and the LRU/eviction
+// accounting that comes with it) rather than leaving an untracked mapping
+// that would otherwise accumulate one per access to a cold block.
+func readSpillFile(path string, size int64) ([]byte, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	mapped, err := syscall.Mmap(int(f.Fd()), 0, int(size), syscall.PROT_READ, syscall.MAP_SHARED)
+	if err != nil {
+		return nil, fmt.Errorf("mmap %s: %w", path, err)
+	}
+	defer syscall.Munmap(mapped)
+
+	data := make([]byte, size)
+	copy(data, mapped)
+	return data, nil
+}
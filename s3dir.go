@@ -0,0 +1,190 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+	"github.com/jacobsa/fuse/fuseops"
+	"github.com/jacobsa/fuse/fuseutil"
+)
+
+// S3DirNode represents a directory synthesized from an S3 key prefix. Its
+// listing is populated lazily on first LookUpInode/ReadDir and kept for
+// S3FSRead.ListingTTL before the next access refreshes it.
+type S3DirNode struct {
+	Name   string
+	Prefix string // full S3 key prefix this directory represents ("" for the bucket root)
+
+	// static marks a root synthesized by NewBackendFSRead to front a single
+	// file with a non-S3 Backend (GCS, Azure, a presigned URL): its
+	// childInodes/dirents are populated once up front and there is no S3
+	// prefix to list, so ensureListed is a no-op.
+	static bool
+
+	// listMu guards listedAt/childInodes/dirents: ensureListed writes them
+	// under Lock() on a refresh, so any read (lookupChild, direntsFrom) needs
+	// at least RLock() - jacobsa/fuse runs every op concurrently on its own
+	// goroutine, and a read racing a refresh's write panics the whole mount.
+	listMu      sync.RWMutex
+	listedAt    time.Time
+	childInodes map[string]fuseops.InodeID
+	dirents     []fuseutil.Dirent
+}
+
+// lookupChild returns the inode for name, if dir has been listed.
+func (dir *S3DirNode) lookupChild(name string) (fuseops.InodeID, bool) {
+	dir.listMu.RLock()
+	defer dir.listMu.RUnlock()
+	id, ok := dir.childInodes[name]
+	return id, ok
+}
+
+// direntsFrom returns the dirents at or after offset. The returned slice
+// aliases dir.dirents as of this call; that's safe to range over after the
+// lock is released because ensureListed only ever replaces dir.dirents
+// wholesale on a refresh, never mutates the existing slice in place.
+func (dir *S3DirNode) direntsFrom(offset fuseops.DirOffset) []fuseutil.Dirent {
+	dir.listMu.RLock()
+	defer dir.listMu.RUnlock()
+	if int(offset) > len(dir.dirents) {
+		return nil
+	}
+	return dir.dirents[offset:]
+}
+
+// ensureListed issues a ListObjectsV2 against dir.Prefix if it has never
+// been listed or its listing is older than fs.ListingTTL, synthesizing a
+// child inode for every object (file) and common prefix (subdirectory)
+// returned.
+func (fs *S3FSRead) ensureListed(ctx context.Context, dir *S3DirNode) error {
+	dir.listMu.Lock()
+	defer dir.listMu.Unlock()
+
+	if dir.static {
+		return nil
+	}
+
+	if dir.childInodes != nil && time.Since(dir.listedAt) < fs.ListingTTL {
+		return nil
+	}
+
+	childInodes := make(map[string]fuseops.InodeID)
+	var dirents []fuseutil.Dirent
+	offset := fuseops.DirOffset(1)
+
+	paginator := s3.NewListObjectsV2Paginator(fs.Client, &s3.ListObjectsV2Input{
+		Bucket:    aws.String(fs.Bucket),
+		Prefix:    aws.String(dir.Prefix),
+		Delimiter: aws.String("/"),
+	})
+
+	for paginator.HasMorePages() {
+		page, err := paginator.NextPage(ctx)
+		if err != nil {
+			return fmt.Errorf("list objects s3://%s/%s: %w", fs.Bucket, dir.Prefix, err)
+		}
+
+		for _, cp := range page.CommonPrefixes {
+			name := strings.TrimSuffix(strings.TrimPrefix(aws.ToString(cp.Prefix), dir.Prefix), "/")
+			if name == "" {
+				continue
+			}
+
+			childPrefix := dir.Prefix + name + "/"
+			inodeID := fs.dirInode(childPrefix, name)
+			childInodes[name] = inodeID
+			dirents = append(dirents, fuseutil.Dirent{
+				Offset: offset,
+				Inode:  inodeID,
+				Name:   name,
+				Type:   fuseutil.DT_Directory,
+			})
+			offset++
+		}
+
+		for _, obj := range page.Contents {
+			key := aws.ToString(obj.Key)
+			name := strings.TrimPrefix(key, dir.Prefix)
+			if name == "" {
+				// The prefix "directory marker" object itself - not a child.
+				continue
+			}
+
+			inodeID := fs.fileInode(key, name, uint64(aws.ToInt64(obj.Size)))
+			childInodes[name] = inodeID
+			dirents = append(dirents, fuseutil.Dirent{
+				Offset: offset,
+				Inode:  inodeID,
+				Name:   name,
+				Type:   fuseutil.DT_File,
+			})
+			offset++
+		}
+	}
+
+	dir.childInodes = childInodes
+	dir.dirents = dirents
+	dir.listedAt = time.Now()
+	return nil
+}
+
+// dirInode returns the inode for the directory at prefix, creating it (and
+// allocating a new inode ID) on first sight. Repeated listings of the
+// parent reuse the same inode for the same prefix.
+func (fs *S3FSRead) dirInode(prefix string, name string) fuseops.InodeID {
+	fs.pathInodeMu.Lock()
+	defer fs.pathInodeMu.Unlock()
+
+	if id, ok := fs.PathInodeIdMap[prefix]; ok {
+		return id
+	}
+
+	id := fs.nextInodeID
+	fs.nextInodeID++
+	fs.PathInodeIdMap[prefix] = id
+	fs.InodeMap[id] = &S3DirNode{Name: name, Prefix: prefix}
+	return id
+}
+
+// fileInode returns the inode for the object at key, creating it on first
+// sight and refreshing its size on subsequent listings.
+func (fs *S3FSRead) fileInode(key string, name string, size uint64) fuseops.InodeID {
+	fs.pathInodeMu.Lock()
+	defer fs.pathInodeMu.Unlock()
+
+	if id, ok := fs.PathInodeIdMap[key]; ok {
+		if node, ok := fs.InodeMap[id].(*S3FileNode); ok {
+			node.Size = size
+		}
+		return id
+	}
+
+	id := fs.nextInodeID
+	fs.nextInodeID++
+	fs.PathInodeIdMap[key] = id
+	fs.InodeMap[id] = &S3FileNode{
+		Name:           name,
+		Size:           size,
+		Backend:        NewS3Backend(fs.Client, fs.Bucket, key),
+		CacheBlockSize: fs.CacheBlockSize,
+		InodeID:        id,
+		Cache:          fs.Cache,
+	}
+	return id
+}
+
+// normalizePrefix turns a user-supplied key prefix into one that is either
+// empty (bucket root) or ends in a single trailing "/", matching the shape
+// ListObjectsV2's CommonPrefixes/Contents keys are trimmed against.
+func normalizePrefix(prefix string) string {
+	prefix = strings.TrimPrefix(prefix, "/")
+	if prefix == "" {
+		return ""
+	}
+	return strings.TrimSuffix(prefix, "/") + "/"
+}